@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// buildFlags turns a comma-separated -tags value into the BuildFlags slice
+// packages.Config expects.
+func buildFlags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return []string{"-tags", tags}
+}
+
+// buildEnv returns the environment packages.Load should run with, overriding
+// GOOS/GOARCH if either was given. A nil result means "use the default
+// environment", which packages.Load interprets as os.Environ().
+func buildEnv(goos, goarch string) []string {
+	if goos == "" && goarch == "" {
+		return nil
+	}
+
+	env := os.Environ()
+	if goos != "" {
+		env = append(env, "GOOS="+goos)
+	}
+	if goarch != "" {
+		env = append(env, "GOARCH="+goarch)
+	}
+	return env
+}
+
+// checkAllTagCombos runs the checker once per unique build-tag combination
+// discovered across the loaded packages (see discoverBuildTagCombos),
+// merging and deduplicating diagnostics across runs. Without this, -tags
+// picks one combination and files gated behind any other are never
+// actually checked.
+//
+// The combinations are discovered from the directories of an initial load
+// with the base -tags, rather than guessed from patterns directly -- a
+// pattern can be "./...", "all", or several patterns at once, none of
+// which is a directory os.ReadDir can use, and all of which should still
+// honor -w.
+func checkAllTagCombos(patterns []string, includeTests bool, overlay map[string][]byte, absTargetFile string) {
+	baseRoots, err := loadRoots(patterns, overlay, includeTests, *buildTags)
+	if err != nil {
+		report(err, absTargetFile)
+		return
+	}
+
+	dirs := map[string]bool{}
+	for _, root := range baseRoots {
+		if dir := packageDir(root); dir != "" {
+			dirs[dir] = true
+		}
+	}
+
+	seenCombo := map[string]bool{}
+	var combos []string
+	for dir := range dirs {
+		dirCombos, err := discoverBuildTagCombos(dir)
+		if err != nil {
+			report(err, absTargetFile)
+			continue
+		}
+		for _, combo := range dirCombos {
+			if !seenCombo[combo] {
+				seenCombo[combo] = true
+				combos = append(combos, combo)
+			}
+		}
+	}
+	combos = append(combos, *buildTags) // always include the base -tags, even with no combos discovered
+
+	seen := map[string]bool{}
+	for _, combo := range combos {
+		roots, err := loadRoots(patterns, overlay, includeTests, combo)
+		if err != nil {
+			report(err, absTargetFile)
+			continue
+		}
+
+		relevant := roots
+		if absTargetFile != "" {
+			relevant = nil
+			if root := packageContaining(roots, absTargetFile); root != nil {
+				relevant = []*packages.Package{root}
+			}
+		}
+
+		for _, root := range relevant {
+			for _, pkgErr := range filterRedundantListErrors(root.Errors) {
+				key := pkgErr.Pos + "|" + pkgErr.Msg
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				report(pkgErr, absTargetFile)
+			}
+		}
+	}
+}
+
+// packageDir returns the directory containing root's files, derived from
+// its file lists since packages.Package has no Dir field of its own.
+func packageDir(root *packages.Package) string {
+	for _, f := range root.GoFiles {
+		return filepath.Dir(f)
+	}
+	for _, f := range root.IgnoredFiles {
+		return filepath.Dir(f)
+	}
+	for _, f := range root.OtherFiles {
+		return filepath.Dir(f)
+	}
+	return ""
+}
+
+// discoverBuildTagCombos scans the Go files directly in dir for //go:build
+// (or legacy // +build) constraints and returns the set of unique,
+// comma-joined tag combinations they reference, so -all-tags can check the
+// package once per combination actually used.
+//
+// -tags is additive: every listed tag evaluates true and every other tag
+// evaluates false, so a file gated on an AND of a positive and a negated
+// tag (e.g. "a && !b") is only satisfied by setting a alone, never by the
+// full combo "a,b" -- that sets b true too, which fails "!b". So besides
+// the full combo, each referenced tag is also tried on its own.
+func discoverBuildTagCombos(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var combos []string
+	add := func(combo string) {
+		if !seen[combo] {
+			seen[combo] = true
+			combos = append(combos, combo)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		src, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		tags := constraintTagsFromSource(src)
+		if len(tags) == 0 {
+			continue
+		}
+
+		add(strings.Join(tags, ","))
+		for _, tag := range tags {
+			add(tag)
+		}
+	}
+	return combos, nil
+}
+
+// constraintTagsFromSource extracts the tag names referenced by a file's
+// //go:build or // +build constraint, ignoring the &&/||/! structure (see
+// discoverBuildTagCombos for how trying each tag individually covers some
+// of what that loses). This is good enough to drive -all-tags; it is not a
+// general constraint evaluator.
+func constraintTagsFromSource(src []byte) []string {
+	seen := map[string]bool{}
+	var tags []string
+
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "package ") {
+			break // constraints must precede the package clause
+		}
+
+		var expr string
+		switch {
+		case strings.HasPrefix(line, "//go:build "):
+			expr = strings.TrimPrefix(line, "//go:build ")
+		case strings.HasPrefix(line, "// +build "):
+			expr = strings.TrimPrefix(line, "// +build ")
+		default:
+			continue
+		}
+
+		for _, field := range strings.Fields(expr) {
+			for _, tag := range strings.FieldsFunc(field, func(r rune) bool {
+				return r == '&' || r == '|' || r == '!' || r == '(' || r == ')'
+			}) {
+				if tag != "" && !seen[tag] {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestConstraintTagsFromSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "go:build and",
+			src:  "//go:build a && !b\n\npackage p\n",
+			want: []string{"a", "b"},
+		},
+		{
+			name: "legacy +build or",
+			src:  "// +build a b\n\npackage p\n",
+			want: []string{"a", "b"},
+		},
+		{
+			name: "no constraint",
+			src:  "package p\n",
+			want: nil,
+		},
+		{
+			name: "stops at package clause",
+			src:  "package p\n\n//go:build a\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := constraintTagsFromSource([]byte(tt.src))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("constraintTagsFromSource(%q) = %v; want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiscoverBuildTagCombosNegatedTag covers a file gated on an AND of a
+// positive and a negated tag: the full AND'd combo sets both tags true,
+// which never satisfies the negated half, so each tag discovered must also
+// be tried on its own.
+func TestDiscoverBuildTagCombosNegatedTag(t *testing.T) {
+	dir := t.TempDir()
+	src := "//go:build a && !b\n\npackage p\n"
+	if err := os.WriteFile(filepath.Join(dir, "f.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	combos, err := discoverBuildTagCombos(dir)
+	if err != nil {
+		t.Fatalf("discoverBuildTagCombos: %v", err)
+	}
+	sort.Strings(combos)
+
+	want := []string{"a", "a,b", "b"}
+	if !reflect.DeepEqual(combos, want) {
+		t.Errorf("discoverBuildTagCombos(%q) = %v; want %v", dir, combos, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// jsonDiagnostic is the shape emitted by -json: one line per reported
+// error, suitable for feeding into a linter or CI annotation formatter.
+type jsonDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Col      int    `json:"col"`
+	EndLine  int    `json:"endLine"`
+	EndCol   int    `json:"endCol"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Kind     string `json:"kind"`
+}
+
+// diagnosticForPkgError converts a packages.Error into the -json output
+// shape, parsing its Pos field with parsePkgErrorPos so a drive-letter
+// colon in the file name is not mistaken for a field separator.
+func diagnosticForPkgError(pkgErr packages.Error) jsonDiagnostic {
+	file, line, col := parsePkgErrorPos(pkgErr.Pos)
+	return jsonDiagnostic{
+		File:     file,
+		Line:     line,
+		Col:      col,
+		EndLine:  line,
+		EndCol:   col,
+		Severity: "error",
+		Message:  pkgErr.Msg,
+		Kind:     kindString(pkgErr.Kind),
+	}
+}
+
+// parsePkgErrorPos splits a packages.Error's Pos field ("file:line:col")
+// into its components. Only the trailing two colon-separated fields are
+// treated as line/col, so a Windows path with a drive letter (e.g.
+// "C:\foo\bar.go:10:5") is still parsed correctly, unlike a plain
+// strings.Split on ":".
+func parsePkgErrorPos(pos string) (file string, line, col int) {
+	if pos == "" {
+		return "", 0, 0
+	}
+
+	parts := strings.Split(pos, ":")
+	if len(parts) >= 3 {
+		if c, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+			if l, err := strconv.Atoi(parts[len(parts)-2]); err == nil {
+				return strings.Join(parts[:len(parts)-2], ":"), l, c
+			}
+		}
+	}
+	if len(parts) >= 2 {
+		if l, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+			return strings.Join(parts[:len(parts)-1], ":"), l, 0
+		}
+	}
+	return pos, 0, 0
+}
+
+// filterRedundantListErrors drops the unpositioned ListError packages.Load
+// adds for a root that failed to "go list" -- its Msg is the raw
+// "# pkg\nfile:line:col: msg" compiler banner and its Pos is always "" --
+// whenever that same root also reports a positioned ParseError or TypeError
+// for the same problem. Without this, -json against a whole package (as
+// opposed to a single -pkg-context file, where the blank-Pos entry happens
+// to get filtered out by the path restriction) double-reports every error:
+// once as the raw banner and once properly positioned.
+func filterRedundantListErrors(errs []packages.Error) []packages.Error {
+	hasPositioned := false
+	for _, err := range errs {
+		if err.Kind != packages.ListError && err.Pos != "" {
+			hasPositioned = true
+			break
+		}
+	}
+	if !hasPositioned {
+		return errs
+	}
+
+	filtered := make([]packages.Error, 0, len(errs))
+	for _, err := range errs {
+		if err.Kind == packages.ListError && err.Pos == "" {
+			continue
+		}
+		filtered = append(filtered, err)
+	}
+	return filtered
+}
+
+// kindString maps a packages.ErrorKind to the string used in the "kind"
+// field of -json output.
+func kindString(kind packages.ErrorKind) string {
+	switch kind {
+	case packages.ParseError:
+		return "parse"
+	case packages.TypeError:
+		return "type"
+	case packages.ListError:
+		return "list"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestParsePkgErrorPos(t *testing.T) {
+	tests := []struct {
+		name     string
+		pos      string
+		wantFile string
+		wantLine int
+		wantCol  int
+	}{
+		{name: "empty", pos: "", wantFile: ""},
+		{name: "file line col", pos: "a.go:10:5", wantFile: "a.go", wantLine: 10, wantCol: 5},
+		{name: "file line only", pos: "a.go:10", wantFile: "a.go", wantLine: 10},
+		{name: "windows drive letter", pos: `C:\foo\bar.go:10:5`, wantFile: `C:\foo\bar.go`, wantLine: 10, wantCol: 5},
+		{name: "no colon", pos: "a.go", wantFile: "a.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, line, col := parsePkgErrorPos(tt.pos)
+			if file != tt.wantFile || line != tt.wantLine || col != tt.wantCol {
+				t.Errorf("parsePkgErrorPos(%q) = (%q, %d, %d); want (%q, %d, %d)",
+					tt.pos, file, line, col, tt.wantFile, tt.wantLine, tt.wantCol)
+			}
+		})
+	}
+}
+
+// TestFilterRedundantListErrors covers -json against a whole package (as
+// opposed to a single -pkg-context file), where packages.Load reports both
+// the raw "go list" banner (Kind ListError, Pos "") and the same error
+// again, properly positioned.
+func TestFilterRedundantListErrors(t *testing.T) {
+	positioned := packages.Error{Pos: "a.go:3:2", Msg: "undefined: x", Kind: packages.TypeError}
+	banner := packages.Error{Pos: "", Msg: "# mypkg\na.go:3:2: undefined: x", Kind: packages.ListError}
+
+	tests := []struct {
+		name string
+		in   []packages.Error
+		want []packages.Error
+	}{
+		{
+			name: "drops banner when a positioned error exists",
+			in:   []packages.Error{banner, positioned},
+			want: []packages.Error{positioned},
+		},
+		{
+			name: "keeps banner when it's the only error",
+			in:   []packages.Error{banner},
+			want: []packages.Error{banner},
+		},
+		{
+			name: "leaves positioned-only errors alone",
+			in:   []packages.Error{positioned},
+			want: []packages.Error{positioned},
+		},
+		{
+			name: "empty",
+			in:   nil,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterRedundantListErrors(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterRedundantListErrors(%v) = %v; want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -31,6 +31,12 @@ test files.
 Otherwise, each path must be the filename of a Go file belonging
 to the same package.
 
+Arguments may also be go list-style patterns -- "./...", "all", a module
+path, or several patterns at once -- in which case gotype loads and checks
+every matching package. With -pkg-context and a single file argument,
+gotype still restricts reported errors to that file, resolving which
+loaded package contains it rather than assuming there's only one root.
+
 Imports are processed by importing directly from the source of
 imported packages (default), or by importing from compiled and
 installed packages (by setting -c to the respective compiler).
@@ -58,6 +64,26 @@ The flags are:
 		consider the entire package when type checking, but only report errors for the given file; default: true
 	-w
 		consider the given directory as the working directory
+	-serve
+		run as a long-lived server, keeping parsed packages warm across
+		requests instead of checking a path and exiting; see the -serve
+		section below
+	-overlay
+		path to a JSON file of {path: contents} overlays for unsaved
+		buffers; see the overlay section below
+	-json
+		emit diagnostics as newline-delimited JSON on stdout, for
+		linters and CI annotation formats, instead of plain text on
+		stderr
+	-tags
+		comma-separated list of build tags to apply
+	-goos
+		GOOS to type-check against (defaults to the running GOOS)
+	-goarch
+		GOARCH to type-check against (defaults to the running GOARCH)
+	-all-tags
+		run once per unique build-tag combination discovered in the
+		package, merging and deduplicating diagnostics across runs
 
 Flags controlling additional output:
 	-ast
@@ -86,10 +112,39 @@ To verify the output of a pipe:
 
 	echo "package foo" | gotype
 
+To run as a server an editor can drive on every keystroke, keeping loaded
+packages warm between requests:
+
+	gotype -serve
+
+Requests and responses are framed like LSP, with a Content-Length header
+followed by a JSON body. See server.go for the request/response shapes.
+
+To check a buffer that has unsaved edits, without writing it to disk:
+
+	gotype -overlay overlay.json dir
+
+overlay.json maps absolute file paths to their in-memory contents (plain
+text or base64), and is threaded through both packages.Load and the
+source-importer fallback, so imports of other unsaved packages are also
+honored. See overlay.go.
+
+To check files gated behind //go:build constraints for another platform:
+
+	gotype -goos=windows -goarch=386 -tags=integration dir
+
+To check every build-tag combination a package actually uses, rather than
+whichever one -tags happens to select:
+
+	gotype -all-tags dir
+
+See buildtags.go.
+
 */
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -104,6 +159,13 @@ var (
 	autoFiles     = flag.Bool("a", true, "if the base file ends in _test.go, include xtest files, otherwise include in-package test files and normal files.")
 	usePkgContext = flag.Bool("pkg-context", true, "check the entire package, but restrict errors to the given file")
 	workingDir    = flag.String("w", "", "use the given directory as the working directory (defaults to cwd)")
+	serveMode     = flag.Bool("serve", false, "run as a long-lived server, accepting check requests over stdio using LSP-style Content-Length framing")
+	overlayFile   = flag.String("overlay", "", "path to a JSON file of {path: contents} overlays (contents plain or base64) for unsaved buffers, mirroring packages.Config.Overlay")
+	jsonOutput    = flag.Bool("json", false, "emit diagnostics as newline-delimited JSON objects on stdout instead of plain text on stderr")
+	buildTags     = flag.String("tags", "", "comma-separated list of build tags, plumbed into packages.Config.BuildFlags and the source-importer fallback")
+	goosFlag      = flag.String("goos", "", "GOOS to type-check against (defaults to the running GOOS)")
+	goarchFlag    = flag.String("goarch", "", "GOARCH to type-check against (defaults to the running GOARCH)")
+	allTags       = flag.Bool("all-tags", false, "run once per unique build-tag combination discovered in the package, merging and deduplicating diagnostics")
 )
 
 var (
@@ -125,7 +187,8 @@ that directory, comprising a single package. Use -t to include the
 test files.
 
 Otherwise, each path must be the filename of a Go file belonging
-to the same package.
+to the same package, or one or more go list-style patterns (./..., all,
+a module path).
 
 Imports are processed by importing directly from the source of
 imported packages (default), or by importing from compiled and
@@ -144,45 +207,84 @@ func usage() {
 }
 
 func report(err error, pathRestriction string) {
-	if pathRestriction != "" {
-		if pkgErr, isPkgErr := err.(packages.Error); isPkgErr {
-			errFileParts := strings.Split(pkgErr.Pos, ":")
-			errFilePath := errFileParts[0]
-			if errFilePath != pathRestriction {
-				return
-			}
+	pkgErr, isPkgErr := err.(packages.Error)
+	if pathRestriction != "" && isPkgErr {
+		errFilePath, _, _ := parsePkgErrorPos(pkgErr.Pos)
+		if errFilePath != pathRestriction {
+			return
 		}
 	}
+
+	if *jsonOutput {
+		var diag jsonDiagnostic
+		if isPkgErr {
+			diag = diagnosticForPkgError(pkgErr)
+		} else {
+			diag = jsonDiagnostic{Severity: "error", Message: err.Error(), Kind: "unknown"}
+		}
+		data, jsonErr := json.Marshal(diag)
+		if jsonErr != nil {
+			// shouldn't happen: jsonDiagnostic is entirely plain fields
+			fmt.Fprintln(os.Stderr, jsonErr)
+			return
+		}
+		fmt.Fprintln(os.Stdout, string(data))
+		errorCount++
+		return
+	}
+
 	fmt.Fprintln(os.Stderr, err)
 	errorCount++
 }
 
-func getPkgFiles(args []string, useContext bool) (string, string, error) {
+// getPkgFiles interprets args either as a single target file (when
+// useContext is set and args names one file directly) or as one or more
+// go list-style patterns -- a directory, "./...", "all", a module path, or
+// several of these -- to be passed straight through to packages.Load.
+func getPkgFiles(args []string, useContext bool) (patterns []string, targetFile string, err error) {
 	if len(args) == 1 {
-		// possibly a directory
 		path := args[0]
-		info, err := os.Stat(path)
-		if err != nil {
-			return "", "", err
-		}
-		if info.IsDir() {
-			return path, "", nil
-		}
+		if info, statErr := os.Stat(path); statErr == nil {
+			if info.IsDir() {
+				return []string{path}, "", nil
+			}
 
-		if useContext {
-			dirName := filepath.Dir(path)
-			if strings.HasPrefix(path, "./") {
-				// filepath.Dir (via filepath.Clean) removes the leading ./
-				dirName = "./" + dirName
+			if useContext {
+				dirName := filepath.Dir(path)
+				if strings.HasPrefix(path, "./") {
+					// filepath.Dir (via filepath.Clean) removes the leading ./
+					dirName = "./" + dirName
+				}
+				return []string{dirName}, path, nil
 			}
-			return dirName, path, nil
+			// a bare file without -pkg-context isn't a valid target on its
+			// own; fall through and let packages.Load reject it as a pattern.
 		}
 	}
 
-	return "", "", fmt.Errorf("cannot specify more than one path")
+	if len(args) == 0 {
+		return nil, "", fmt.Errorf("no path given")
+	}
+
+	return args, "", nil
+}
+
+// packageContaining returns whichever root loaded a file at absFile, or nil
+// if none did. Needed once patterns can expand to more than one package.
+func packageContaining(roots []*packages.Package, absFile string) *packages.Package {
+	for _, root := range roots {
+		for _, f := range root.GoFiles {
+			if f == absFile {
+				return root
+			}
+		}
+	}
+	return nil
 }
 
-func checkPkgFiles(pkgPath, targetFile string) {
+// loadRoots loads patterns with the given overlay and build tags (in
+// addition to -goos/-goarch, which apply regardless of tags).
+func loadRoots(patterns []string, overlay map[string][]byte, includeTests bool, tags string) ([]*packages.Package, error) {
 	wd := *workingDir
 	if wd == "" {
 		var err error
@@ -192,44 +294,102 @@ func checkPkgFiles(pkgPath, targetFile string) {
 		}
 	}
 
-	includeTests := *autoFiles && targetFile != "" && strings.HasSuffix(targetFile, "_test.go")
-
 	cfg := &packages.Config{
 		Dir: wd,
 		Mode: packages.LoadTypes,
 		Tests: includeTests,
+		Overlay: overlay,
+		BuildFlags: buildFlags(tags),
+		Env: buildEnv(*goosFlag, *goarchFlag),
 	}
+	return packages.Load(cfg, patterns...)
+}
 
-	var err error
-	targetFile, err = filepath.Abs(targetFile)
-	if err != nil {
-		report(err, targetFile)
+// reportRoots reports the errors from roots, restricted to absTargetFile
+// (via the package that contains it) when one is given.
+func reportRoots(roots []*packages.Package, absTargetFile string) {
+	if absTargetFile != "" {
+		root := packageContaining(roots, absTargetFile)
+		if root == nil {
+			report(fmt.Errorf("no loaded package contains %s", absTargetFile), "")
+			return
+		}
+		for _, err := range filterRedundantListErrors(root.Errors) {
+			report(err, absTargetFile)
+		}
 		return
 	}
 
-	roots, err := packages.Load(cfg, pkgPath)
-	if err != nil {
-		report(err, targetFile)
+	for _, root := range roots {
+		for _, err := range filterRedundantListErrors(root.Errors) {
+			report(err, absTargetFile)
+		}
+	}
+}
+
+func checkPkgFiles(patterns []string, targetFile string, overlay map[string][]byte) {
+	// A bare list of existing .go files (not a directory or a go
+	// list-style pattern) is checked directly via Importer instead of
+	// packages.Load; -all-tags stays on the packages.Load path below
+	// since loadRoots already accepts file-list patterns just fine.
+	if targetFile == "" && !*allTags && isFileList(patterns) {
+		checkFileList(patterns, overlay)
 		return
 	}
-	for _, root := range roots {
-		for _, err := range root.Errors {
+
+	includeTests := *autoFiles && targetFile != "" && strings.HasSuffix(targetFile, "_test.go")
+
+	var absTargetFile string
+	if targetFile != "" {
+		var err error
+		absTargetFile, err = filepath.Abs(targetFile)
+		if err != nil {
 			report(err, targetFile)
+			return
 		}
 	}
+
+	if *allTags {
+		checkAllTagCombos(patterns, includeTests, overlay, absTargetFile)
+		return
+	}
+
+	roots, err := loadRoots(patterns, overlay, includeTests, *buildTags)
+	if err != nil {
+		report(err, absTargetFile)
+		return
+	}
+	reportRoots(roots, absTargetFile)
 }
 
 func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	pkgPath, targetFile, err := getPkgFiles(flag.Args(), *usePkgContext)
+	if *serveMode {
+		if err := runServer(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	patterns, targetFile, err := getPkgFiles(flag.Args(), *usePkgContext)
 	if err != nil {
 		report(err, "")
 		os.Exit(2)
 	}
 
-	checkPkgFiles(pkgPath, targetFile)
+	var overlay map[string][]byte
+	if *overlayFile != "" {
+		overlay, err = loadOverlay(*overlayFile)
+		if err != nil {
+			report(err, "")
+			os.Exit(2)
+		}
+	}
+
+	checkPkgFiles(patterns, targetFile, overlay)
 	if errorCount > 0 {
 		os.Exit(2)
 	}
@@ -2,10 +2,38 @@ package main
 
 import (
 	pathpkg "path"
-	"go/types"
+	"go/ast"
 	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
 )
 
+// fset is the shared FileSet every AST parsed by this program is recorded
+// against, so positions reported by the type-checker line up with the
+// files parseFiles(Overlay) handed it.
+var fset = token.NewFileSet()
+
+// parseFiles parses the named Go source files in dir into ASTs against the
+// shared fset.
+func parseFiles(dir string, filenames []string) ([]*ast.File, error) {
+	files := make([]*ast.File, len(filenames))
+	for i, filename := range filenames {
+		path := filename
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filename)
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = file
+	}
+	return files, nil
+}
+
 // Importer is a types.ImporterFor that uses a "fast" importer, and if that fails,
 // falls back to a "slower" implementation.
 type Importer struct {
@@ -13,6 +41,37 @@ type Importer struct {
 	cwd string
 	packages map[string]*types.Package
 	config *types.Config
+	// overlay holds in-memory contents for unsaved files, keyed by absolute
+	// path, mirroring packages.Config.Overlay. It's consulted only by the
+	// fallback path in ImportFrom; mainImporter reads packages from disk
+	// and never sees it, so an imported package that mainImporter can
+	// load is type-checked without its overlay contents.
+	overlay map[string][]byte
+	// goos, goarch, and buildTags mirror -goos, -goarch, and -tags for the
+	// fallback path in ImportFrom, which doesn't go through packages.Load
+	// (and therefore doesn't see its BuildFlags/Env) and so needs its own
+	// build.Context. Empty fields mean "use build.Default's value".
+	goos      string
+	goarch    string
+	buildTags []string
+}
+
+// buildContext returns the build.Context ImportFrom's fallback path should
+// use: a copy of build.Default with i.goos, i.goarch, and i.buildTags
+// applied on top, instead of using build.Default directly. It returns a
+// pointer since build.Context's Import method has a pointer receiver.
+func (i *Importer) buildContext() *build.Context {
+	ctx := build.Default
+	if i.goos != "" {
+		ctx.GOOS = i.goos
+	}
+	if i.goarch != "" {
+		ctx.GOARCH = i.goarch
+	}
+	if len(i.buildTags) > 0 {
+		ctx.BuildTags = i.buildTags
+	}
+	return &ctx
 }
 
 func (i *Importer) Import(path string) (*types.Package, error) {
@@ -28,14 +87,14 @@ func (i *Importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*type
 	fullDir := pathpkg.Join(i.cwd, srcDir)
 	pkg, err := i.mainImporter.ImportFrom(path, fullDir, mode)
 	if err != nil {
-		buildPkg, err := build.Default.Import(path, fullDir, 0 /* No `AllowBinary` because it messes with modules */)
+		buildPkg, err := i.buildContext().Import(path, fullDir, 0 /* No `AllowBinary` because it messes with modules */)
 		if err != nil {
 			return nil, err
 		}
 
 		// TODO: support xtest and test files here too?
 		filenames := append(buildPkg.GoFiles, buildPkg.CgoFiles...)
-		files, err := parseFiles(buildPkg.Dir, filenames)
+		files, err := parseFilesOverlay(buildPkg.Dir, filenames, i.overlay)
 		if err != nil {
 			return nil, err
 		}
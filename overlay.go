@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"os"
+	"path/filepath"
+)
+
+// loadOverlay reads the JSON file given to -overlay: an object mapping
+// absolute file paths to their in-memory contents, mirroring the shape of
+// packages.Config.Overlay. Each value may be either plain source text or
+// base64-encoded source text, so editors can send binary-safe payloads
+// without worrying about JSON string escaping.
+func loadOverlay(path string) (map[string][]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var asStrings map[string]string
+	if err := json.Unmarshal(raw, &asStrings); err != nil {
+		return nil, err
+	}
+
+	overlay := make(map[string][]byte, len(asStrings))
+	for file, contents := range asStrings {
+		overlay[file] = decodeOverlayValue(contents)
+	}
+	return overlay, nil
+}
+
+// decodeOverlayValue decodes s as base64 if possible, falling back to using
+// it verbatim as source text otherwise.
+func decodeOverlayValue(s string) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return decoded
+	}
+	return []byte(s)
+}
+
+// parseFilesOverlay is like parseFiles, but honors overlay (absolute path
+// -> content) for any of filenames that have an in-memory override,
+// falling back to parseFiles for the rest. This is what lets
+// Importer.ImportFrom's fallback path see unsaved edits in an imported
+// package, the same way the fast path does via packages.Config.Overlay.
+func parseFilesOverlay(dir string, filenames []string, overlay map[string][]byte) ([]*ast.File, error) {
+	if len(overlay) == 0 {
+		return parseFiles(dir, filenames)
+	}
+
+	var onDisk []string
+	files := make([]*ast.File, len(filenames))
+	onDiskIdx := make([]int, 0, len(filenames))
+	for i, filename := range filenames {
+		path := filename
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filename)
+		}
+
+		src, ok := overlay[path]
+		if !ok {
+			onDisk = append(onDisk, filename)
+			onDiskIdx = append(onDiskIdx, i)
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = file
+	}
+
+	if len(onDisk) > 0 {
+		parsed, err := parseFiles(dir, onDisk)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range onDiskIdx {
+			files[i] = parsed[j]
+		}
+	}
+
+	return files, nil
+}
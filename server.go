@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// serverRequest is a single request framed with an LSP-style Content-Length
+// header. Only the handful of methods gotype needs to drive from an editor
+// are supported.
+type serverRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// checkParams is the payload for the "check" method: type-check the package
+// containing File (or Package, if given) and report diagnostics restricted
+// to File.
+type checkParams struct {
+	File    string `json:"file"`
+	Package string `json:"package"`
+}
+
+// didChangeParams is the payload for the "didChange" notification: File now
+// has the given in-memory Content (plain text or base64, like -overlay),
+// which overrides its on-disk contents until a later didChange clears it by
+// sending an empty Content.
+type didChangeParams struct {
+	File    string `json:"file"`
+	Content string `json:"content"`
+}
+
+// diagnostic is the structured form of a packages.Error, shaped for editors
+// driving gotype over the server protocol.
+type diagnostic struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Package  string `json:"package"`
+}
+
+// serverResponse is written back for every request; Error is set instead of
+// Diagnostics when the request itself could not be serviced.
+type serverResponse struct {
+	Diagnostics []diagnostic `json:"diagnostics,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// cachedPkg is a loaded package plus the mtimes of its files at load time,
+// so a later request can tell whether it needs reloading.
+type cachedPkg struct {
+	pkg    *packages.Package
+	mtimes map[string]time.Time
+}
+
+// server keeps parsed packages warm across requests so editors can drive
+// gotype on every keystroke without paying the packages.Load cost each
+// time. Packages are cached by directory and invalidated either lazily
+// (a source file's mtime has advanced past what was loaded) or explicitly
+// via a "didChange" notification.
+// overlay holds in-memory contents for unsaved files, keyed by absolute
+// path, so a "didChange" notification can be honored without a round trip
+// through disk.
+type server struct {
+	mu      sync.Mutex
+	pkgs    map[string]*cachedPkg // keyed by package directory
+	overlay map[string][]byte
+}
+
+func newServer() *server {
+	return &server{
+		pkgs:    make(map[string]*cachedPkg),
+		overlay: make(map[string][]byte),
+	}
+}
+
+// runServer reads Content-Length framed requests from r and writes framed
+// responses to w until r is exhausted.
+func runServer(r io.Reader, w io.Writer) error {
+	srv := newServer()
+	br := bufio.NewReader(r)
+	for {
+		body, err := readFrame(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req serverRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeFrame(w, serverResponse{Error: err.Error()})
+			continue
+		}
+
+		switch req.Method {
+		case "check":
+			var params checkParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				writeFrame(w, serverResponse{Error: err.Error()})
+				continue
+			}
+			diags, err := srv.check(params)
+			resp := serverResponse{Diagnostics: diags}
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			writeFrame(w, resp)
+		case "didChange":
+			var params didChangeParams
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				writeFrame(w, serverResponse{Error: err.Error()})
+				continue
+			}
+			srv.setOverlay(params.File, params.Content)
+			writeFrame(w, serverResponse{})
+		default:
+			writeFrame(w, serverResponse{Error: fmt.Sprintf("unknown method %q", req.Method)})
+		}
+	}
+}
+
+// readFrame reads a single Content-Length framed message, LSP-style.
+func readFrame(br *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, found := strings.Cut(line, ":"); found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length header: %v", err)
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeFrame writes v as a Content-Length framed JSON message.
+func writeFrame(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// check type-checks the package for params, reusing a cached load where
+// possible, and returns diagnostics restricted to params.File (when given).
+func (s *server) check(params checkParams) ([]diagnostic, error) {
+	absFile, err := resolveCheckFile(params.File)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := params.Package
+	if dir == "" {
+		if absFile == "" {
+			return nil, fmt.Errorf("check requires a file or package")
+		}
+		dir = filepath.Dir(absFile)
+	}
+
+	pkg, err := s.load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return diagnosticsForFile(pkg, absFile), nil
+}
+
+// resolveCheckFile resolves file to an absolute path, same as
+// checkPkgFiles does for absTargetFile in gotype2.go. Diagnostics from
+// packages.Load always carry absolute paths, so comparing against a
+// relative file -- the normal way an editor names the buffer it just
+// edited -- would otherwise silently match nothing.
+func resolveCheckFile(file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	return filepath.Abs(file)
+}
+
+// diagnosticsForFile converts pkg's errors to diagnostics, restricted to
+// absFile (an absolute path, or "" for no restriction).
+func diagnosticsForFile(pkg *packages.Package, absFile string) []diagnostic {
+	var diags []diagnostic
+	for _, pkgErr := range pkg.Errors {
+		d := diagnosticFromError(pkgErr)
+		if absFile != "" && d.Path != absFile {
+			continue
+		}
+		d.Package = pkg.PkgPath
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// load returns the cached package for dir, reloading it if it's missing or
+// stale.
+func (s *server) load(dir string) (*packages.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.pkgs[dir]; ok && !cached.stale() {
+		return cached.pkg, nil
+	}
+
+	cfg := &packages.Config{Dir: dir, Mode: packages.LoadTypes, Overlay: s.overlay}
+	pkgs, err := packages.Load(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no package found in %s", dir)
+	}
+
+	pkg := pkgs[0]
+	s.pkgs[dir] = &cachedPkg{pkg: pkg, mtimes: fileMtimes(pkg.GoFiles)}
+	return pkg, nil
+}
+
+// setOverlay records file's in-memory content (or clears it, if content is
+// empty) and drops the cached package containing it, so the next check for
+// it triggers a fresh load against the new overlay.
+func (s *server) setOverlay(file, content string) {
+	if abs, err := resolveCheckFile(file); err == nil {
+		file = abs
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if content == "" {
+		delete(s.overlay, file)
+	} else {
+		s.overlay[file] = decodeOverlayValue(content)
+	}
+	delete(s.pkgs, filepath.Dir(file))
+}
+
+func (c *cachedPkg) stale() bool {
+	for file, mtime := range c.mtimes {
+		info, err := os.Stat(file)
+		if err != nil || info.ModTime().After(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileMtimes(files []string) map[string]time.Time {
+	mtimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			mtimes[file] = info.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// diagnosticFromError converts a packages.Error into a diagnostic, using
+// the same Pos parsing as -json's jsonDiagnostic so a Windows drive-letter
+// path isn't misread as the file name.
+func diagnosticFromError(pkgErr packages.Error) diagnostic {
+	file, line, col := parsePkgErrorPos(pkgErr.Pos)
+	return diagnostic{
+		Path:     file,
+		Line:     line,
+		Column:   col,
+		Severity: "error",
+		Message:  pkgErr.Msg,
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "simple",
+			input: "Content-Length: 13\r\n\r\n{\"ok\":true}\r\n",
+			want:  "{\"ok\":true}\r\n",
+		},
+		{
+			name:  "header case insensitive",
+			input: "content-length: 2\r\n\r\n{}",
+			want:  "{}",
+		},
+		{
+			name:    "missing header",
+			input:   "\r\n{}",
+			wantErr: true,
+		},
+		{
+			name:    "bad length",
+			input:   "Content-Length: nope\r\n\r\n{}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrame(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readFrame(%q) = %q, nil; want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFrame(%q): %v", tt.input, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readFrame(%q) = %q; want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckRelativeFile reproduces the server.check false negative: an
+// editor names the buffer it just edited relative to the workspace (the
+// normal way), but diagnostics from packages.Load always carry absolute
+// paths.
+func TestCheckRelativeFile(t *testing.T) {
+	relFile := filepath.Join("mypkg", "a.go")
+	absFile, err := filepath.Abs(relFile)
+	if err != nil {
+		t.Fatalf("filepath.Abs(%q): %v", relFile, err)
+	}
+
+	pkg := &packages.Package{
+		PkgPath: "mypkg",
+		Errors: []packages.Error{
+			{Pos: fmt.Sprintf("%s:3:2", absFile), Msg: "undefined: x", Kind: packages.TypeError},
+		},
+	}
+
+	resolved, err := resolveCheckFile(relFile)
+	if err != nil {
+		t.Fatalf("resolveCheckFile(%q): %v", relFile, err)
+	}
+	if resolved != absFile {
+		t.Fatalf("resolveCheckFile(%q) = %q; want %q", relFile, resolved, absFile)
+	}
+
+	diags := diagnosticsForFile(pkg, resolved)
+	if len(diags) != 1 {
+		t.Fatalf("diagnosticsForFile with relative file resolved to %q = %d diagnostics; want 1", resolved, len(diags))
+	}
+	if diags[0].Path != absFile {
+		t.Errorf("diags[0].Path = %q; want %q", diags[0].Path, absFile)
+	}
+}